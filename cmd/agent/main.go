@@ -0,0 +1,61 @@
+// Command agent runs a long-lived worker that registers with a coordinator
+// and executes the tasks it is assigned, instead of exposing an inbound
+// WebSocket/gRPC endpoint of its own.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"dev.rubentxu.devops-platform/internal/adapters/agent"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	var (
+		server     = flag.String("server", "", "Coordinator address, e.g. localhost:50051")
+		secret     = flag.String("secret", "", "Shared secret sent to the coordinator in the Register message (cmd/coordinator does not check it)")
+		maxProcs   = flag.Int("max-procs", 1, "Maximum number of tasks to run concurrently")
+		platform   = flag.String("platform", "docker", "Execution platform this agent provides (docker, kubernetes, ...)")
+		retryLimit = flag.Int("retry-limit", 10, "Maximum reconnect attempts before giving up")
+		backoff    = flag.Duration("backoff", 5*time.Second, "Delay between RequestTask polls when idle")
+		labels     = flag.String("labels", "", "Comma-separated capability labels advertised to the scheduler")
+	)
+	flag.Parse()
+
+	if *server == "" {
+		log.Fatal("--server is required")
+	}
+
+	var capabilities []string
+	if *labels != "" {
+		capabilities = strings.Split(*labels, ",")
+	}
+
+	a, err := agent.New(uuid.NewString(), agent.Config{
+		ServerAddress: *server,
+		Secret:        *secret,
+		MaxProcs:      *maxProcs,
+		Platform:      *platform,
+		Capabilities:  capabilities,
+		RetryLimit:    *retryLimit,
+		Backoff:       *backoff,
+	})
+	if err != nil {
+		log.Fatalf("Error creating agent: %v", err)
+	}
+	defer a.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("Agent exited: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+// Command coordinator listens for agent registrations (see cmd/agent) and
+// dispatches submitted tasks to the least-loaded agent whose capabilities
+// match, over the pb.AgentService gRPC contract. A task queued because no
+// matching agent had free capacity goes to the first matching agent with
+// free capacity that next requests work, not to the least-loaded one.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"dev.rubentxu.devops-platform/internal/adapters/coordinator"
+
+	pb "dev.rubentxu.devops-platform/adapters/grpc/protos/agent"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("listen", ":50051", "Address agents register against")
+	flag.Parse()
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v", *addr, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterAgentServiceServer(grpcServer, coordinator.New())
+
+	log.Printf("Coordinator listening on %s", *addr)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Coordinator exited: %v", err)
+	}
+}
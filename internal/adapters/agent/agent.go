@@ -0,0 +1,259 @@
+// Package agent turns a remote_process_client connection into a long-lived
+// worker that pulls tasks from a coordinator instead of accepting inbound
+// WebSocket connections, letting the module scale horizontally across
+// machines that don't need to be individually addressable.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	pb "dev.rubentxu.devops-platform/adapters/grpc/protos/agent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config holds the flags that shape an Agent's behavior.
+type Config struct {
+	ServerAddress   string
+	Secret          string
+	MaxProcs        int
+	Platform        string
+	Capabilities    []string
+	RetryLimit      int
+	Backoff         time.Duration
+	HeartbeatPeriod time.Duration
+}
+
+// Agent pulls task assignments from a coordinator over a single
+// bidirectional gRPC stream and executes up to Config.MaxProcs of them
+// concurrently.
+type Agent struct {
+	cfg    Config
+	id     string
+	client pb.AgentServiceClient
+	conn   *grpc.ClientConn
+	sem    chan struct{}
+
+	streamMu sync.Mutex
+	stream   pb.AgentService_RegisterClient
+}
+
+// New dials the coordinator at cfg.ServerAddress and returns an Agent ready
+// to Run.
+func New(agentID string, cfg Config) (*Agent, error) {
+	conn, err := grpc.NewClient(
+		cfg.ServerAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to coordinator: %v", err)
+	}
+
+	if cfg.MaxProcs <= 0 {
+		cfg.MaxProcs = 1
+	}
+	if cfg.HeartbeatPeriod <= 0 {
+		cfg.HeartbeatPeriod = 10 * time.Second
+	}
+
+	return &Agent{
+		cfg:    cfg,
+		id:     agentID,
+		client: pb.NewAgentServiceClient(conn),
+		conn:   conn,
+		sem:    make(chan struct{}, cfg.MaxProcs),
+	}, nil
+}
+
+// Close releases the connection to the coordinator.
+func (a *Agent) Close() {
+	if err := a.conn.Close(); err != nil {
+		log.Printf("Error closing agent connection: %v", err)
+	}
+}
+
+// Run registers the agent with the coordinator and then loops requesting
+// tasks, executing up to cfg.MaxProcs of them concurrently, and sending
+// periodic heartbeats, until ctx is done. A stream dropped by a transient
+// error is rebuilt and re-registered up to cfg.RetryLimit times, with
+// cfg.Backoff between attempts, instead of the agent exiting on the first
+// network blip.
+func (a *Agent) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		err := a.runOnce(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		attempt++
+		if attempt > a.cfg.RetryLimit {
+			return fmt.Errorf("agent stream failed after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Agent stream to coordinator dropped (%v), reconnecting (attempt %d/%d)", err, attempt, a.cfg.RetryLimit)
+
+		select {
+		case <-time.After(a.cfg.Backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runOnce registers one gRPC stream with the coordinator and serves it until
+// it errors or ctx is done.
+func (a *Agent) runOnce(ctx context.Context) error {
+	stream, err := a.client.Register(ctx)
+	if err != nil {
+		return fmt.Errorf("error opening agent stream: %v", err)
+	}
+
+	a.streamMu.Lock()
+	a.stream = stream
+	a.streamMu.Unlock()
+
+	err = a.send(&pb.AgentMessage{
+		Body: &pb.AgentMessage_Register{
+			Register: &pb.Register{
+				AgentId:      a.id,
+				Capabilities: a.cfg.Capabilities,
+				Platform:     a.cfg.Platform,
+				MaxProcs:     int32(a.cfg.MaxProcs),
+				Secret:       a.cfg.Secret,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error registering agent: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Scoped to this attempt so a reconnect doesn't leave the old stream's
+	// heartbeatLoop running forever once a fresh one is spawned for the
+	// replacement stream.
+	heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
+	defer cancelHeartbeat()
+	go a.heartbeatLoop(heartbeatCtx)
+
+	for {
+		if err := a.requestTask(); err != nil {
+			return fmt.Errorf("error requesting task: %v", err)
+		}
+
+		msg, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("error receiving from coordinator: %v", err)
+		}
+
+		switch body := msg.Body.(type) {
+		case *pb.AgentMessage_Idle:
+			select {
+			case <-time.After(a.cfg.Backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case *pb.AgentMessage_Assignment:
+			a.sem <- struct{}{}
+			wg.Add(1)
+			go func(assignment *pb.TaskAssignment) {
+				defer wg.Done()
+				defer func() { <-a.sem }()
+				a.executeTask(ctx, assignment)
+			}(body.Assignment)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// send serializes every stream.Send call behind streamMu: gRPC client
+// streams aren't safe for concurrent Send, and requestTask, heartbeatLoop,
+// and one executeTask goroutine per in-flight assignment would otherwise all
+// write to the same stream at once.
+func (a *Agent) send(msg *pb.AgentMessage) error {
+	a.streamMu.Lock()
+	defer a.streamMu.Unlock()
+	return a.stream.Send(msg)
+}
+
+func (a *Agent) requestTask() error {
+	return a.send(&pb.AgentMessage{
+		Body: &pb.AgentMessage_RequestTask{
+			RequestTask: &pb.RequestTask{AgentId: a.id},
+		},
+	})
+}
+
+// executeTask runs a single assignment's command locally and, once the
+// process exits, sends its combined stdout/stderr back to the coordinator
+// as a single TaskOutput frame (skipped if empty), followed by a TaskResult.
+func (a *Agent) executeTask(ctx context.Context, assignment *pb.TaskAssignment) {
+	cmd := exec.CommandContext(ctx, assignment.Command[0], assignment.Command[1:]...)
+	cmd.Dir = assignment.WorkingDirectory
+	for k, v := range assignment.Environment {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	output, err := cmd.CombinedOutput()
+	result := &pb.TaskResult{
+		TaskId:   assignment.TaskId,
+		ExitCode: int32(cmd.ProcessState.ExitCode()),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if len(output) > 0 {
+		if sendErr := a.send(&pb.AgentMessage{
+			Body: &pb.AgentMessage_Output{
+				Output: &pb.TaskOutput{TaskId: assignment.TaskId, Output: string(output)},
+			},
+		}); sendErr != nil {
+			log.Printf("Error streaming output for task %s: %v", assignment.TaskId, sendErr)
+		}
+	}
+
+	if sendErr := a.send(&pb.AgentMessage{
+		Body: &pb.AgentMessage_Result{Result: result},
+	}); sendErr != nil {
+		log.Printf("Error sending result for task %s: %v", assignment.TaskId, sendErr)
+	}
+}
+
+// heartbeatLoop periodically reports the agent's current load (slots in
+// use out of MaxProcs) so the coordinator's scheduler can dispatch new work
+// to the least-loaded agent.
+func (a *Agent) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.HeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			inUse := len(a.sem)
+			err := a.send(&pb.AgentMessage{
+				Body: &pb.AgentMessage_Heartbeat{
+					Heartbeat: &pb.Heartbeat{
+						AgentId:      a.id,
+						SlotsInUse:   int32(inUse),
+						FreeCapacity: int32(a.cfg.MaxProcs - inUse),
+					},
+				},
+			})
+			if err != nil {
+				log.Printf("Error sending heartbeat: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
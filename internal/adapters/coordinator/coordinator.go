@@ -0,0 +1,259 @@
+// Package coordinator implements the server half of the agent pull-protocol
+// defined by pb.AgentService: it accepts Register streams from agents
+// started by cmd/agent, tracks their advertised capacity/capabilities, and
+// dispatches Submit'd tasks to the least-loaded agent whose platform and
+// capabilities satisfy the task. A task that had to be queued goes to the
+// first matching agent with free capacity that next sends RequestTask.
+package coordinator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	pb "dev.rubentxu.devops-platform/adapters/grpc/protos/agent"
+)
+
+// Task is a unit of work waiting to run on some matching agent. Submit
+// queues it (or dispatches it immediately, if a matching agent already has
+// free capacity) and returns a channel that receives its TaskResult.
+type Task struct {
+	TaskID               string
+	Command              []string
+	Environment          map[string]string
+	WorkingDirectory     string
+	Platform             string
+	RequiredCapabilities []string
+}
+
+// agentConn tracks one registered agent: its advertised capacity and
+// capabilities, its live stream, and how many of its slots the Coordinator
+// currently believes are in use (kept in sync by Heartbeat messages and by
+// the Coordinator's own dispatch decisions).
+type agentConn struct {
+	id           string
+	platform     string
+	capabilities map[string]struct{}
+	maxProcs     int
+	slotsInUse   int
+	stream       pb.AgentService_RegisterServer
+}
+
+func (a *agentConn) freeCapacity() int {
+	return a.maxProcs - a.slotsInUse
+}
+
+func (a *agentConn) matches(platform string, required []string) bool {
+	if platform != "" && a.platform != platform {
+		return false
+	}
+	for _, c := range required {
+		if _, ok := a.capabilities[c]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Coordinator implements pb.AgentServiceServer. Register serves one agent's
+// bidirectional stream for as long as it stays connected; Submit is called
+// by whatever is queuing work (the worker package, a future scheduling
+// endpoint, ...) to hand the coordinator a Task.
+type Coordinator struct {
+	pb.UnimplementedAgentServiceServer
+
+	mu      sync.Mutex
+	agents  map[string]*agentConn
+	queue   []Task
+	results map[string]chan TaskResult
+}
+
+// TaskResult is what Submit's returned channel receives once the agent
+// assigned to a Task reports completion.
+type TaskResult struct {
+	ExitCode int32
+	Error    string
+}
+
+// New returns an empty Coordinator ready to be registered with a
+// grpc.Server via pb.RegisterAgentServiceServer.
+func New() *Coordinator {
+	return &Coordinator{
+		agents:  make(map[string]*agentConn),
+		results: make(map[string]chan TaskResult),
+	}
+}
+
+// Submit queues t and immediately tries to push it to the least-loaded
+// registered agent whose platform/capabilities match, rather than waiting
+// for that agent's next RequestTask poll. It returns a buffered channel
+// that receives exactly one TaskResult once some agent reports t done.
+func (c *Coordinator) Submit(t Task) <-chan TaskResult {
+	ch := make(chan TaskResult, 1)
+
+	c.mu.Lock()
+	c.results[t.TaskID] = ch
+	best := c.leastLoadedMatchLocked(t.Platform, t.RequiredCapabilities)
+	if best != nil {
+		best.slotsInUse++
+	} else {
+		c.queue = append(c.queue, t)
+	}
+	c.mu.Unlock()
+
+	if best != nil {
+		c.assign(best, t)
+	}
+
+	return ch
+}
+
+// leastLoadedMatchLocked must be called with mu held.
+func (c *Coordinator) leastLoadedMatchLocked(platform string, required []string) *agentConn {
+	var best *agentConn
+	for _, conn := range c.agents {
+		if conn.freeCapacity() <= 0 || !conn.matches(platform, required) {
+			continue
+		}
+		if best == nil || conn.freeCapacity() > best.freeCapacity() {
+			best = conn
+		}
+	}
+	return best
+}
+
+// Register implements the agent-facing half of AgentService: the first
+// message on the stream must be Register, after which it loops handling
+// RequestTask/Heartbeat/Output/Result until the agent disconnects or the
+// stream errors, at which point the agent is dropped from the registry.
+func (c *Coordinator) Register(stream pb.AgentService_RegisterServer) error {
+	msg, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("error reading register message: %w", err)
+	}
+	reg := msg.GetRegister()
+	if reg == nil {
+		return fmt.Errorf("first message on an agent stream must be Register")
+	}
+
+	conn := &agentConn{
+		id:           reg.AgentId,
+		platform:     reg.Platform,
+		capabilities: toSet(reg.Capabilities),
+		maxProcs:     int(reg.MaxProcs),
+		stream:       stream,
+	}
+	c.addAgent(conn)
+	defer c.removeAgent(conn.id)
+	log.Printf("Agent %s registered (platform=%s, max_procs=%d)", conn.id, conn.platform, conn.maxProcs)
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error receiving from agent %s: %w", conn.id, err)
+		}
+
+		switch body := msg.Body.(type) {
+		case *pb.AgentMessage_RequestTask:
+			c.serveRequest(conn)
+		case *pb.AgentMessage_Heartbeat:
+			c.updateLoad(conn.id, int(body.Heartbeat.SlotsInUse))
+		case *pb.AgentMessage_Output:
+			// Submit's caller only needs the terminal Result to resolve its
+			// TaskResult channel; streamed output has nowhere to go yet, so
+			// it's logged rather than dropped silently.
+			log.Printf("Task %s output: %s", body.Output.TaskId, body.Output.Output)
+		case *pb.AgentMessage_Result:
+			c.resolveResult(body.Result)
+		}
+	}
+}
+
+func (c *Coordinator) addAgent(conn *agentConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agents[conn.id] = conn
+}
+
+func (c *Coordinator) removeAgent(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.agents, id)
+}
+
+func (c *Coordinator) updateLoad(id string, slotsInUse int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.agents[id]; ok {
+		conn.slotsInUse = slotsInUse
+	}
+}
+
+// serveRequest replies to one RequestTask from conn: with the oldest
+// queued task conn's platform/capabilities match and it has free capacity
+// for, or an Idle message telling the agent to back off and poll again.
+func (c *Coordinator) serveRequest(conn *agentConn) {
+	c.mu.Lock()
+	var task *Task
+	if conn.freeCapacity() > 0 {
+		for i := range c.queue {
+			if conn.matches(c.queue[i].Platform, c.queue[i].RequiredCapabilities) {
+				t := c.queue[i]
+				task = &t
+				c.queue = append(c.queue[:i], c.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	if task != nil {
+		conn.slotsInUse++
+	}
+	c.mu.Unlock()
+
+	if task == nil {
+		if err := conn.stream.Send(&pb.AgentMessage{Body: &pb.AgentMessage_Idle{Idle: &pb.Idle{}}}); err != nil {
+			log.Printf("Error sending idle to agent %s: %v", conn.id, err)
+		}
+		return
+	}
+
+	c.assign(conn, *task)
+}
+
+func (c *Coordinator) assign(conn *agentConn, t Task) {
+	assignment := &pb.AgentMessage{Body: &pb.AgentMessage_Assignment{Assignment: &pb.TaskAssignment{
+		TaskId:           t.TaskID,
+		Command:          t.Command,
+		Environment:      t.Environment,
+		WorkingDirectory: t.WorkingDirectory,
+	}}}
+	if err := conn.stream.Send(assignment); err != nil {
+		log.Printf("Error assigning task %s to agent %s: %v", t.TaskID, conn.id, err)
+	}
+}
+
+func (c *Coordinator) resolveResult(result *pb.TaskResult) {
+	c.mu.Lock()
+	ch, ok := c.results[result.TaskId]
+	if ok {
+		delete(c.results, result.TaskId)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		ch <- TaskResult{ExitCode: result.ExitCode, Error: result.Error}
+		close(ch)
+	}
+}
+
+func toSet(ss []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(ss))
+	for _, s := range ss {
+		set[s] = struct{}{}
+	}
+	return set
+}
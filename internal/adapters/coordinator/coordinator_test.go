@@ -0,0 +1,40 @@
+package coordinator
+
+import "testing"
+
+// TestLeastLoadedMatchPrefersMostFreeCapacity guards the core scheduling
+// decision: among registered agents that match a task's platform and
+// capabilities, Submit must pick the one with the most free
+// capacity, not just the first one found.
+func TestLeastLoadedMatchPrefersMostFreeCapacity(t *testing.T) {
+	c := New()
+	busy := &agentConn{id: "busy", platform: "docker", capabilities: toSet([]string{"gpu"}), maxProcs: 4, slotsInUse: 3}
+	idle := &agentConn{id: "idle", platform: "docker", capabilities: toSet([]string{"gpu"}), maxProcs: 4, slotsInUse: 1}
+	wrongPlatform := &agentConn{id: "wrong-platform", platform: "kubernetes", capabilities: toSet([]string{"gpu"}), maxProcs: 4}
+	missingCap := &agentConn{id: "missing-cap", platform: "docker", capabilities: toSet(nil), maxProcs: 4}
+	c.agents[busy.id] = busy
+	c.agents[idle.id] = idle
+	c.agents[wrongPlatform.id] = wrongPlatform
+	c.agents[missingCap.id] = missingCap
+
+	best := c.leastLoadedMatchLocked("docker", []string{"gpu"})
+	if best == nil || best.id != "idle" {
+		t.Fatalf("leastLoadedMatchLocked = %v, want %q", best, "idle")
+	}
+}
+
+// TestLeastLoadedMatchNoCandidates guards the no-match case: zero free
+// capacity or a platform mismatch must both result in the task staying
+// queued instead of panicking or picking a bad fit.
+func TestLeastLoadedMatchNoCandidates(t *testing.T) {
+	c := New()
+	full := &agentConn{id: "full", platform: "docker", capabilities: toSet([]string{"gpu"}), maxProcs: 2, slotsInUse: 2}
+	c.agents[full.id] = full
+
+	if best := c.leastLoadedMatchLocked("docker", []string{"gpu"}); best != nil {
+		t.Fatalf("leastLoadedMatchLocked = %v, want nil (no free capacity)", best)
+	}
+	if best := c.leastLoadedMatchLocked("windows", nil); best != nil {
+		t.Fatalf("leastLoadedMatchLocked = %v, want nil (platform mismatch)", best)
+	}
+}
@@ -0,0 +1,130 @@
+package remote_process_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClientConfig controls how aggressively the client reconnects a broken
+// StartProcess/MonitorHealth stream instead of giving up on the first
+// transient error.
+type ClientConfig struct {
+	// RetryLimit caps how many consecutive reconnect attempts are made
+	// before the stream is abandoned. Defaults to math.MaxInt32, i.e.
+	// effectively unbounded, mirroring how long-running CI agents keep
+	// retrying across network blips.
+	RetryLimit int
+
+	// Backoff is the initial delay between reconnect attempts.
+	Backoff time.Duration
+
+	// MaxBackoff caps the exponential growth of Backoff.
+	MaxBackoff time.Duration
+}
+
+// DefaultClientConfig returns the configuration used when New is called
+// without a ClientOption that overrides it.
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		RetryLimit: math.MaxInt32,
+		Backoff:    15 * time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+}
+
+// ClientOption configures a Client at construction time.
+type ClientOption func(*Client)
+
+// WithClientConfig overrides the reconnect behavior of StartProcess and
+// MonitorHealth.
+func WithClientConfig(cfg ClientConfig) ClientOption {
+	return func(c *Client) {
+		c.cfg = cfg
+	}
+}
+
+// isTransient reports whether err is worth reconnecting for, as opposed to
+// a terminal error that should be surfaced to the caller immediately.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxBackoffDoublings bounds how many times backoffWithJitter will double
+// cfg.Backoff: time.Duration is an int64 of nanoseconds, so doubling much
+// past this overflows regardless of cfg.MaxBackoff.
+const maxBackoffDoublings = 40
+
+// maxSaneBackoff is the ceiling backoffWithJitter enforces when
+// cfg.MaxBackoff is left unset (zero). RetryLimit defaults to
+// math.MaxInt32, so a caller that only overrides Backoff/RetryLimit must
+// still never see delay double until it overflows time.Duration's int64
+// nanoseconds.
+const maxSaneBackoff = 24 * time.Hour
+
+// backoffWithJitter returns the delay to wait before the given attempt
+// (1-indexed), growing exponentially from cfg.Backoff up to cfg.MaxBackoff
+// (or maxSaneBackoff, if MaxBackoff is unset) and adding up to 20% jitter so
+// many reconnecting clients don't retry in lockstep. Doubling stops as soon
+// as delay reaches that ceiling, so delay can never overflow into a
+// negative duration even with RetryLimit's effectively-unbounded attempt
+// counts.
+func backoffWithJitter(cfg ClientConfig, attempt int) time.Duration {
+	ceiling := cfg.MaxBackoff
+	if ceiling <= 0 {
+		ceiling = maxSaneBackoff
+	}
+
+	delay := cfg.Backoff
+	doublings := attempt - 1
+	if doublings > maxBackoffDoublings {
+		doublings = maxBackoffDoublings
+	}
+	for i := 0; i < doublings && delay < ceiling; i++ {
+		delay *= 2
+	}
+	if delay > ceiling {
+		delay = ceiling
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+// sleepOrDone waits for d or returns ctx.Err() if ctx is cancelled first.
+func sleepOrDone(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func logReconnect(processID string, attempt int, delay time.Duration, err error) {
+	log.Printf("Stream for process %s dropped (%v), reconnecting in %s (attempt %d)", processID, err, delay, attempt)
+}
@@ -0,0 +1,46 @@
+package remote_process_client
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBackoffWithJitterCapsAtMaxBackoff guards against the overflow bug
+// where a large attempt count made the uncapped exponential blow past
+// int64's range and come back around as a negative duration.
+func TestBackoffWithJitterCapsAtMaxBackoff(t *testing.T) {
+	cfg := ClientConfig{
+		Backoff:    15 * time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+
+	for _, attempt := range []int{1, 5, 31, 1000, 1 << 30} {
+		delay := backoffWithJitter(cfg, attempt)
+		if delay <= 0 {
+			t.Fatalf("attempt %d: delay = %v, want > 0", attempt, delay)
+		}
+		// Allow up to 20% jitter on top of MaxBackoff.
+		if max := cfg.MaxBackoff + cfg.MaxBackoff/5 + 1; delay > max {
+			t.Fatalf("attempt %d: delay = %v, want <= %v", attempt, delay, max)
+		}
+	}
+}
+
+// TestBackoffWithJitterCapsWithoutMaxBackoff guards against the same
+// overflow when MaxBackoff is left at its zero value -- the scenario
+// RetryLimit's effectively-unbounded default is meant to support, where a
+// caller only overrides Backoff/RetryLimit and reconnects across many
+// network blips.
+func TestBackoffWithJitterCapsWithoutMaxBackoff(t *testing.T) {
+	cfg := ClientConfig{Backoff: 15 * time.Second}
+
+	for _, attempt := range []int{1, 5, 31, 1000, 1 << 30} {
+		delay := backoffWithJitter(cfg, attempt)
+		if delay <= 0 {
+			t.Fatalf("attempt %d: delay = %v, want > 0", attempt, delay)
+		}
+		if max := maxSaneBackoff + maxSaneBackoff/5 + 1; delay > max {
+			t.Fatalf("attempt %d: delay = %v, want <= %v", attempt, delay, max)
+		}
+	}
+}
@@ -16,10 +16,11 @@ import (
 type Client struct {
 	client pb.RemoteProcessServiceClient
 	conn   *grpc.ClientConn
+	cfg    ClientConfig
 }
 
 // New creates a new instance of the client
-func New(serverAddress string) (*Client, error) {
+func New(serverAddress string, opts ...ClientOption) (*Client, error) {
 	conn, err := grpc.NewClient(
 		serverAddress,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -29,57 +30,171 @@ func New(serverAddress string) (*Client, error) {
 	}
 
 	client := pb.NewRemoteProcessServiceClient(conn)
-	return &Client{client: client, conn: conn}, nil
+	c := &Client{client: client, conn: conn, cfg: DefaultClientConfig()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// StartProcessOption configures optional behavior of StartProcess, such as
+// attaching a stdin reader for interactive processes.
+type StartProcessOption func(*startProcessConfig)
+
+type startProcessConfig struct {
+	stdin io.Reader
+}
+
+// WithStdin attaches r as the process's standard input. Bytes read from r
+// are forwarded to the server over the same stream used for the initial
+// ProcessStartRequest, so this must be used with servers that support
+// channel 3 (control-in) framing; servers that don't simply ignore it.
+func WithStdin(r io.Reader) StartProcessOption {
+	return func(cfg *startProcessConfig) {
+		cfg.stdin = r
+	}
+}
+
+// StartProcess sends a request to start a process on the server and streams
+// the output via outputChan. If the underlying stream drops with a
+// transient error (Unavailable, DeadlineExceeded, EOF before the process
+// reported a terminal output), it is transparently rebuilt and resumed from
+// the last acknowledged sequence number, up to c.cfg.RetryLimit attempts,
+// using exponential backoff with jitter. outputChan is closed once the
+// process reaches a terminal output, the stream fails with a non-transient
+// error, ctx is done, or retries are exhausted.
+func (c *Client) StartProcess(ctx context.Context, processID string, command []string, env map[string]string, workingDir string, outputChan chan<- *pb.ProcessOutput, opts ...StartProcessOption) error {
+	cfg := &startProcessConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	stream, lastSeqNo, err := c.openProcessStream(ctx, processID, command, env, workingDir, 0, false)
+	if err != nil {
+		return err
+	}
+
+	if cfg.stdin != nil {
+		go c.pumpStdin(ctx, stream, processID, cfg.stdin)
+	} else if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("error closing send stream: %v", err)
+	}
+
+	go c.streamProcessOutput(ctx, processID, command, env, workingDir, stream, lastSeqNo, outputChan)
+
+	return nil
 }
 
-// StartProcess sends a request to start a process on the server and receives the output via a channel
-func (c *Client) StartProcess(ctx context.Context, processID string, command []string, env map[string]string, workingDir string, outputChan chan<- *pb.ProcessOutput) error {
-	// Create the stream
+// openProcessStream creates a StartProcess stream and sends the initial
+// request. When resume is true it carries LastSeqNo so the server can
+// replay buffered output the client has not yet acknowledged.
+func (c *Client) openProcessStream(ctx context.Context, processID string, command []string, env map[string]string, workingDir string, lastSeqNo int64, resume bool) (pb.RemoteProcessService_StartProcessClient, int64, error) {
 	stream, err := c.client.StartProcess(ctx)
 	if err != nil {
-		return fmt.Errorf("error creating stream: %v", err)
+		return nil, lastSeqNo, fmt.Errorf("error creating stream: %v", err)
 	}
 
-	// Send the initial request
 	err = stream.Send(&pb.ProcessStartRequest{
 		ProcessId:        processID,
 		Command:          command,
 		Environment:      env,
 		WorkingDirectory: workingDir,
+		Resume:           resume,
+		LastSeqNo:        lastSeqNo,
 	})
 	if err != nil {
-		return fmt.Errorf("error sending request: %v", err)
+		return nil, lastSeqNo, fmt.Errorf("error sending request: %v", err)
 	}
 
-	// Close the send stream
-	if err := stream.CloseSend(); err != nil {
-		return fmt.Errorf("error closing send stream: %v", err)
-	}
+	return stream, lastSeqNo, nil
+}
 
-	// Process responses in a goroutine
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Recovered in StartProcess: %v", r)
-			}
-			close(outputChan)
-		}()
-		for {
-			resp, err := stream.Recv()
-			if err == io.EOF {
-				return // End of stream
-			}
-			if err != nil {
-				log.Printf("Error receiving response: %v", err)
+// streamProcessOutput owns a StartProcess stream's receive loop and
+// transparently reconnects it on transient errors, forwarding every
+// ProcessOutput to outputChan and closing it once the process is done, a
+// non-transient error occurs, ctx is done, or reconnection is exhausted.
+func (c *Client) streamProcessOutput(ctx context.Context, processID string, command []string, env map[string]string, workingDir string, stream pb.RemoteProcessService_StartProcessClient, lastSeqNo int64, outputChan chan<- *pb.ProcessOutput) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered in StartProcess: %v", r)
+		}
+		close(outputChan)
+	}()
+
+	attempt := 0
+	for {
+		resp, err := stream.Recv()
+		if err == nil {
+			attempt = 0
+			lastSeqNo = resp.SeqNo
+			outputChan <- resp
+			if resp.Terminal {
 				return
 			}
+			continue
+		}
 
-			// Send the output to the channel
-			outputChan <- resp
+		// A Terminal output returns above, so an EOF here means the stream
+		// ended before the process did and is retried like a transient error.
+		transient := err == io.EOF || isTransient(err)
+		if !transient || attempt >= c.cfg.RetryLimit {
+			log.Printf("Error receiving response for process %s: %v", processID, err)
+			return
+		}
+
+		attempt++
+		delay := backoffWithJitter(c.cfg, attempt)
+		logReconnect(processID, attempt, delay, err)
+		if waitErr := sleepOrDone(ctx, delay); waitErr != nil {
+			return
+		}
+
+		newStream, newSeq, openErr := c.openProcessStream(ctx, processID, command, env, workingDir, lastSeqNo, true)
+		if openErr != nil {
+			log.Printf("Error reconnecting stream for process %s: %v", processID, openErr)
+			continue
+		}
+		stream, lastSeqNo = newStream, newSeq
+	}
+}
+
+// pumpStdin reads from r until EOF or ctx is done, forwarding each chunk to
+// the server as a ProcessStartRequest carrying Stdin bytes, then closes the
+// send side of the stream so the server knows no more input is coming.
+func (c *Client) pumpStdin(ctx context.Context, stream pb.RemoteProcessService_StartProcessClient, processID string, r io.Reader) {
+	defer func() {
+		if err := stream.CloseSend(); err != nil {
+			log.Printf("Error closing send stream for process %s: %v", processID, err)
 		}
 	}()
 
-	return nil
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		n, err := r.Read(buf)
+		if n > 0 {
+			sendErr := stream.Send(&pb.ProcessStartRequest{
+				ProcessId: processID,
+				Stdin:     append([]byte(nil), buf[:n]...),
+			})
+			if sendErr != nil {
+				log.Printf("Error forwarding stdin for process %s: %v", processID, sendErr)
+				return
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading stdin for process %s: %v", processID, err)
+			return
+		}
+	}
 }
 
 // StopProcess sends a request to stop a process on the server
@@ -99,69 +214,97 @@ func (c *Client) StopProcess(ctx context.Context, processID string) (bool, strin
 	return response.Success, response.Message, nil
 }
 
-// MonitorHealth inicia el monitoreo de la salud de un proceso
+// MonitorHealth inicia el monitoreo de la salud de un proceso. Al igual que
+// StartProcess, reconecta automáticamente el stream ante errores transitorios
+// en lugar de terminar el monitoreo silenciosamente.
 func (c *Client) MonitorHealth(ctx context.Context, processID string, checkInterval int64, healthChan chan<- *pb.HealthStatus) error {
+	stream, err := c.openHealthStream(ctx, processID, checkInterval)
+	if err != nil {
+		return err
+	}
+
+	go c.streamHealth(ctx, processID, checkInterval, stream, healthChan)
+
+	return nil
+}
+
+func (c *Client) openHealthStream(ctx context.Context, processID string, checkInterval int64) (pb.RemoteProcessService_MonitorHealthClient, error) {
 	stream, err := c.client.MonitorHealth(ctx)
 	if err != nil {
-		return fmt.Errorf("error creating stream: %v", err)
+		return nil, fmt.Errorf("error creating stream: %v", err)
 	}
 
-	// Enviar la solicitud inicial
-	err = stream.Send(&pb.HealthCheckRequest{
+	if err := stream.Send(&pb.HealthCheckRequest{
 		ProcessId:     processID,
 		CheckInterval: checkInterval,
-	})
-	if err != nil {
-		return fmt.Errorf("error sending request: %v", err)
+	}); err != nil {
+		return nil, fmt.Errorf("error sending request: %v", err)
 	}
 
-	// Procesar respuestas en una goroutine
-	go func() {
-		// Usar un defer recover para manejar posibles pánicos
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Recovered in MonitorHealth: %v", r)
-			}
-		}()
+	return stream, nil
+}
 
-		for {
-			select {
-			case <-ctx.Done():
-				// El contexto fue cancelado, salir limpiamente
-				return
-			default:
-				resp, err := stream.Recv()
-				if err == io.EOF {
-					return
-				}
-				if err != nil {
-					if ctx.Err() == context.Canceled {
-						// Contexto cancelado, salir silenciosamente
-						return
-					}
-					log.Printf("Error receiving response: %v", err)
-					select {
-					case healthChan <- &pb.HealthStatus{
-						ProcessId: processID,
-						IsRunning: false,
-						Status:    fmt.Sprintf("Error receiving response: %v", err),
-					}:
-					case <-ctx.Done():
-					}
-					return
-				}
+func (c *Client) streamHealth(ctx context.Context, processID string, checkInterval int64, stream pb.RemoteProcessService_MonitorHealthClient, healthChan chan<- *pb.HealthStatus) {
+	// Usar un defer recover para manejar posibles pánicos
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered in MonitorHealth: %v", r)
+		}
+	}()
 
-				// Enviar el estado de salud al canal
+	attempt := 0
+	for {
+		select {
+		case <-ctx.Done():
+			// El contexto fue cancelado, salir limpiamente
+			return
+		default:
+			resp, err := stream.Recv()
+			if err == nil {
+				attempt = 0
 				select {
 				case healthChan <- resp:
 				case <-ctx.Done():
+				}
+				continue
+			}
+
+			if err == io.EOF {
+				return
+			}
+			if ctx.Err() == context.Canceled {
+				// Contexto cancelado, salir silenciosamente
+				return
+			}
+
+			if isTransient(err) && attempt < c.cfg.RetryLimit {
+				attempt++
+				delay := backoffWithJitter(c.cfg, attempt)
+				logReconnect(processID, attempt, delay, err)
+				if sleepOrDone(ctx, delay) != nil {
 					return
 				}
+				newStream, openErr := c.openHealthStream(ctx, processID, checkInterval)
+				if openErr != nil {
+					log.Printf("Error reconnecting health stream for process %s: %v", processID, openErr)
+					continue
+				}
+				stream = newStream
+				continue
 			}
-		}
-	}()
 
-	return nil
+			log.Printf("Error receiving response: %v", err)
+			select {
+			case healthChan <- &pb.HealthStatus{
+				ProcessId: processID,
+				IsRunning: false,
+				Status:    fmt.Sprintf("Error receiving response: %v", err),
+			}:
+			case <-ctx.Done():
+			}
+			return
+		}
+	}
 }
 
 // Close closes the gRPC connection
@@ -0,0 +1,78 @@
+// Package remote_process_server holds the server-side counterpart to
+// remote_process_client's stream reconnect logic: an in-memory ring buffer
+// that lets a StartProcess handler replay output a reconnecting client
+// missed, keyed by the ProcessOutput.SeqNo it last acknowledged.
+package remote_process_server
+
+import (
+	"sync"
+
+	pb "dev.rubentxu.devops-platform/adapters/grpc/protos/remote_process"
+)
+
+// DefaultBufferCapacity bounds how many ProcessOutput messages OutputBuffer
+// retains per process when NewOutputBuffer is called with capacity <= 0.
+const DefaultBufferCapacity = 1024
+
+// OutputBuffer is a bounded, in-memory ring buffer of each process's most
+// recent ProcessOutput messages, keyed by process ID. The StartProcess
+// handler appends to it as output is produced and calls Since to replay
+// everything past a resuming ProcessStartRequest's LastSeqNo when a client reconnects
+// mid-stream; Forget releases a process's buffer once it can no longer be
+// resumed.
+type OutputBuffer struct {
+	capacity int
+
+	mu  sync.Mutex
+	log map[string][]*pb.ProcessOutput
+}
+
+// NewOutputBuffer builds an OutputBuffer that retains at most capacity
+// messages per process, falling back to DefaultBufferCapacity if capacity
+// is not positive.
+func NewOutputBuffer(capacity int) *OutputBuffer {
+	if capacity <= 0 {
+		capacity = DefaultBufferCapacity
+	}
+	return &OutputBuffer{capacity: capacity, log: make(map[string][]*pb.ProcessOutput)}
+}
+
+// Append records output for processID, evicting the oldest buffered message
+// once the process's log is at capacity.
+func (b *OutputBuffer) Append(processID string, output *pb.ProcessOutput) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := append(b.log[processID], output)
+	if len(entries) > b.capacity {
+		entries = entries[len(entries)-b.capacity:]
+	}
+	b.log[processID] = entries
+}
+
+// Since returns the buffered ProcessOutput messages for processID with
+// SeqNo strictly greater than afterSeqNo, in order, for replay on a
+// resuming ProcessStartRequest. Messages older than the buffer's capacity are no longer
+// available and are simply absent from the result; callers that need to
+// detect a gap should compare afterSeqNo against the first returned SeqNo.
+func (b *OutputBuffer) Since(processID string, afterSeqNo int64) []*pb.ProcessOutput {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := b.log[processID]
+	replay := make([]*pb.ProcessOutput, 0, len(entries))
+	for _, entry := range entries {
+		if entry.SeqNo > afterSeqNo {
+			replay = append(replay, entry)
+		}
+	}
+	return replay
+}
+
+// Forget discards processID's buffered output once it is no longer
+// resumable, e.g. after the client has acknowledged the terminal message.
+func (b *OutputBuffer) Forget(processID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.log, processID)
+}
@@ -0,0 +1,41 @@
+package remote_process_server
+
+import (
+	"testing"
+
+	pb "dev.rubentxu.devops-platform/adapters/grpc/protos/remote_process"
+)
+
+func TestOutputBufferSinceReplaysOnlyNewerMessages(t *testing.T) {
+	buf := NewOutputBuffer(4)
+	for seq := int64(1); seq <= 3; seq++ {
+		buf.Append("p1", &pb.ProcessOutput{ProcessId: "p1", SeqNo: seq})
+	}
+
+	replay := buf.Since("p1", 1)
+	if len(replay) != 2 || replay[0].SeqNo != 2 || replay[1].SeqNo != 3 {
+		t.Fatalf("Since(1) = %+v, want seq 2 and 3", replay)
+	}
+}
+
+func TestOutputBufferEvictsOldestBeyondCapacity(t *testing.T) {
+	buf := NewOutputBuffer(2)
+	for seq := int64(1); seq <= 3; seq++ {
+		buf.Append("p1", &pb.ProcessOutput{ProcessId: "p1", SeqNo: seq})
+	}
+
+	replay := buf.Since("p1", 0)
+	if len(replay) != 2 || replay[0].SeqNo != 2 || replay[1].SeqNo != 3 {
+		t.Fatalf("Since(0) = %+v, want seq 2 and 3 only (seq 1 evicted)", replay)
+	}
+}
+
+func TestOutputBufferForgetClearsProcess(t *testing.T) {
+	buf := NewOutputBuffer(4)
+	buf.Append("p1", &pb.ProcessOutput{ProcessId: "p1", SeqNo: 1})
+	buf.Forget("p1")
+
+	if replay := buf.Since("p1", 0); len(replay) != 0 {
+		t.Fatalf("Since after Forget = %+v, want empty", replay)
+	}
+}
@@ -0,0 +1,179 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any
+// resolver implementations will be copied through when generating and any
+// unknown code will be moved to the end. A NEW FILE is generated for each
+// schema file by default, the resolver.go here is kept as a single file via
+// gqlgen.yml's `resolver.layout: single-file`.
+//
+// QueryResolver, MutationResolver, SubscriptionResolver, CreateTaskInput,
+// TaskOutputEvent, TaskEvent, and TaskEventFilter below all come from that
+// generated code (see server.go's go:generate directive) rather than being
+// defined in this package -- this file does not compile on its own until
+// `go generate ./...` has been run once against schema.graphqls.
+
+import (
+	"context"
+	"fmt"
+
+	"dev.rubentxu.devops-platform/worker/internal/adapters/worker"
+	"dev.rubentxu.devops-platform/worker/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// Resolver is the root dependency graph for every generated resolver type.
+// It reuses the same domain layer as WSHandler (domain.Task, worker.Worker,
+// the event bus) so /ws and /graphql share code paths instead of
+// duplicating task lifecycle logic.
+type Resolver struct {
+	worker *worker.Worker
+}
+
+// NewResolver builds the root resolver backing the /graphql endpoint.
+func NewResolver(w *worker.Worker) *Resolver {
+	return &Resolver{worker: w}
+}
+
+func (r *Resolver) Query() QueryResolver {
+	return &queryResolver{r}
+}
+
+func (r *Resolver) Mutation() MutationResolver {
+	return &mutationResolver{r}
+}
+
+func (r *Resolver) Subscription() SubscriptionResolver {
+	return &subscriptionResolver{r}
+}
+
+type queryResolver struct{ *Resolver }
+
+func (q *queryResolver) Tasks(ctx context.Context) ([]domain.Task, error) {
+	return q.worker.GetTasks()
+}
+
+func (q *queryResolver) Task(ctx context.Context, id string) (*domain.Task, error) {
+	task, err := q.worker.GetTask(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+type mutationResolver struct{ *Resolver }
+
+func (m *mutationResolver) CreateTask(ctx context.Context, input CreateTaskInput) (*domain.Task, error) {
+	env := make(map[string]string, len(input.Env))
+	for _, kv := range input.Env {
+		env[kv.Key] = kv.Value
+	}
+
+	task := domain.Task{
+		ID:    uuid.New(),
+		Name:  input.Name,
+		State: domain.Scheduled,
+		WorkerSpec: domain.WorkerSpec{
+			Type:       domain.InstanceType(valueOr(input.InstanceType, "docker")),
+			Image:      input.Image,
+			Command:    input.Command,
+			Env:        env,
+			WorkingDir: valueOr(input.WorkingDir, ""),
+		},
+	}
+
+	if _, err := m.worker.AddTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("error creating task: %w", err)
+	}
+	return &task, nil
+}
+
+func (m *mutationResolver) StopTask(ctx context.Context, id string) (*domain.Task, error) {
+	if err := m.worker.StopTask(id); err != nil {
+		return nil, fmt.Errorf("error stopping task %s: %w", id, err)
+	}
+	task, err := m.worker.GetTask(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+type subscriptionResolver struct{ *Resolver }
+
+// TaskOutput attaches to the output stream of the already-running task id,
+// translated into the TaskOutputEvent GraphQL type, over a
+// graphql-transport-ws subscription. It never creates a task itself: `id`
+// must name one already started via CreateTask (GraphQL) or create_task
+// (WebSocket).
+func (s *subscriptionResolver) TaskOutput(ctx context.Context, id string) (<-chan *TaskOutputEvent, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid task id %q: %w", id, err)
+	}
+
+	outputChan, err := s.worker.GetTaskOutput(id)
+	if err != nil {
+		return nil, fmt.Errorf("error subscribing to task %s output: %w", id, err)
+	}
+
+	events := make(chan *TaskOutputEvent)
+	go func() {
+		defer close(events)
+		for output := range outputChan {
+			select {
+			case events <- &TaskOutputEvent{
+				TaskID:  id,
+				Output:  output.Output,
+				IsError: output.IsError,
+				Status:  output.Status.String(),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// TaskEvents streams events (task_created, task_running, task_output,
+// task_completed, task_failed, task_stopped) for every task matching
+// filter, reusing the same worker.Events()
+// broker introduced for the WSHandler subscribe action.
+func (s *subscriptionResolver) TaskEvents(ctx context.Context, filter *TaskEventFilter) (<-chan *TaskEvent, error) {
+	eventFilter := worker.EventFilter{All: true}
+	if filter != nil {
+		eventFilter = worker.EventFilter{
+			TaskIDs:   filter.TaskIds,
+			NameGlobs: filter.NameGlobs,
+			States:    filter.States,
+			All:       filter.All != nil && *filter.All,
+		}
+	}
+
+	broker, cancel := s.worker.Events().Subscribe(eventFilter)
+	events := make(chan *TaskEvent)
+	go func() {
+		defer close(events)
+		defer cancel()
+		for evt, ok := <-broker; ok; evt, ok = <-broker {
+			select {
+			case events <- &TaskEvent{
+				Kind:       string(evt.Kind),
+				TaskID:     evt.TaskID,
+				State:      evt.State,
+				OccurredAt: evt.OccurredAt,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func valueOr(v *string, fallback string) string {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
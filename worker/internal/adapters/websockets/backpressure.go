@@ -0,0 +1,276 @@
+package websockets
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// OutputPolicy selects how a connWriter behaves once its queue is full.
+type OutputPolicy string
+
+const (
+	// PolicyBlock is the historical behavior: the producer (handleCreateTask,
+	// demuxTaskOutput, ...) blocks until the peer drains the queue.
+	PolicyBlock OutputPolicy = "block"
+
+	// PolicyDropOldest evicts the oldest queued frame to make room for the
+	// new one, counting each evicted frame in ws_dropped_frames_total. The
+	// number of evicted bytes is not counted.
+	PolicyDropOldest OutputPolicy = "drop_oldest"
+
+	// PolicyCoalesce merges a new stdout/stderr frame into the previously
+	// queued frame on the same channel, up to MaxBytes, instead of queuing
+	// a separate frame per chunk of output.
+	PolicyCoalesce OutputPolicy = "coalesce"
+)
+
+// ParsePolicy maps the `backpressure` query param onto an OutputPolicy,
+// defaulting to PolicyBlock (the pre-existing behavior) for anything
+// unrecognized.
+func ParsePolicy(raw string) OutputPolicy {
+	switch OutputPolicy(raw) {
+	case PolicyDropOldest, PolicyCoalesce:
+		return OutputPolicy(raw)
+	default:
+		return PolicyBlock
+	}
+}
+
+const (
+	defaultMaxQueuedMessages = 1024
+	defaultMaxQueuedBytes    = 4 * 1024 * 1024
+)
+
+// QueueConfig bounds a connection's outbound queue.
+type QueueConfig struct {
+	MaxMessages int
+	MaxBytes    int
+	Policy      OutputPolicy
+}
+
+// DefaultQueueConfig matches the sizing called out in the backpressure
+// design: 1024 messages or 4 MiB, whichever is hit first.
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		MaxMessages: defaultMaxQueuedMessages,
+		MaxBytes:    defaultMaxQueuedBytes,
+		Policy:      PolicyBlock,
+	}
+}
+
+var (
+	wsQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ws_queue_depth",
+		Help: "Number of frames currently queued for a WebSocket connection.",
+	}, []string{"conn_id"})
+
+	wsDroppedFramesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_dropped_frames_total",
+		Help: "Frames evicted from a connection's outbound queue under the drop_oldest or coalesce policy.",
+	}, []string{"conn_id"})
+
+	wsWriteDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ws_write_duration_seconds",
+		Help:    "Time spent writing a single frame to a WebSocket connection.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"conn_id"})
+)
+
+// queuedFrame is one unit of outbound work: either a JSON/text message, a
+// multiplexed binary frame, or a control frame (ping/close).
+type queuedFrame struct {
+	msgType   int
+	data      []byte
+	channel   int // Channel value when msgType == websocket.BinaryMessage, else -1; coalescing key
+	isControl bool
+}
+
+// connWriter is the single goroutine allowed to call conn.Write*, so
+// sendPing, handleCreateTask, streamTaskOutput, and the deferred close no
+// longer race on the same *websocket.Conn (gorilla/websocket forbids
+// concurrent writers). Callers enqueue frames; connWriter applies the
+// connection's QueueConfig.Policy when the queue is full.
+type connWriter struct {
+	conn   *websocket.Conn
+	cfg    QueueConfig
+	connID string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	frames  []queuedFrame
+	control []queuedFrame
+	bytes   int
+	closed  bool
+	done    chan struct{}
+}
+
+func newConnWriter(conn *websocket.Conn, cfg QueueConfig, connID string) *connWriter {
+	w := &connWriter{conn: conn, cfg: cfg, connID: connID, done: make(chan struct{})}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// markClosed flips closed and wakes run(), without waiting for it to exit.
+// run() calls this itself on a fatal write error, where blocking on done
+// (as Close does) would deadlock the very goroutine that closes it.
+func (w *connWriter) markClosed() {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+// Close stops the writer goroutine and blocks until it has actually
+// drained its queue and exited, so callers can safely close the underlying
+// connection right after without racing still-queued writes (including the
+// close handshake frame enqueued just before Close is called). It also
+// removes this connection's per-conn_id Prometheus labels, which otherwise
+// accumulate forever across short-lived connections.
+func (w *connWriter) Close() {
+	w.markClosed()
+	<-w.done
+
+	wsQueueDepth.DeleteLabelValues(w.connID)
+	wsDroppedFramesTotal.DeleteLabelValues(w.connID)
+	wsWriteDurationSeconds.DeleteLabelValues(w.connID)
+}
+
+func (w *connWriter) enqueueJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.enqueue(queuedFrame{msgType: websocket.TextMessage, data: data, channel: -1})
+	return nil
+}
+
+func (w *connWriter) enqueueBinary(ch Channel, payload []byte) {
+	w.enqueue(queuedFrame{msgType: websocket.BinaryMessage, data: encodeFrame(ch, payload), channel: int(ch)})
+}
+
+func (w *connWriter) enqueueControl(messageType int, data []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.control = append(w.control, queuedFrame{msgType: messageType, data: data, isControl: true})
+	w.cond.Signal()
+}
+
+// enqueue applies the connection's backpressure policy and appends f to the
+// queue, waking the writer goroutine.
+func (w *connWriter) enqueue(f queuedFrame) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	switch w.cfg.Policy {
+	case PolicyDropOldest:
+		for len(w.frames) > 0 && (len(w.frames) >= w.cfg.MaxMessages || w.bytes+len(f.data) > w.cfg.MaxBytes) {
+			dropped := w.frames[0]
+			w.frames = w.frames[1:]
+			w.bytes -= len(dropped.data)
+			wsDroppedFramesTotal.WithLabelValues(w.connID).Inc()
+		}
+		w.frames = append(w.frames, f)
+		w.bytes += len(f.data)
+
+	case PolicyCoalesce:
+		mergeable := f.channel == int(ChannelStdout) || f.channel == int(ChannelStderr)
+		if n := len(w.frames); n > 0 && mergeable && w.frames[n-1].channel == f.channel &&
+			w.bytes+len(f.data) <= w.cfg.MaxBytes {
+			// Frames are [channel-byte][payload]; merge payloads, keep one prefix.
+			w.frames[n-1].data = append(w.frames[n-1].data, f.data[1:]...)
+			w.bytes += len(f.data) - 1
+		} else {
+			// Can't merge into the queue tail (different channel than f, or
+			// merging would bust MaxBytes) -- demuxTaskOutput interleaves a
+			// ChannelStatus frame after every chunk, so this is the common
+			// case. Still bound the queue by evicting the oldest frame, the
+			// same as PolicyDropOldest, instead of growing it unboundedly.
+			for len(w.frames) > 0 && (len(w.frames) >= w.cfg.MaxMessages || w.bytes+len(f.data) > w.cfg.MaxBytes) {
+				dropped := w.frames[0]
+				w.frames = w.frames[1:]
+				w.bytes -= len(dropped.data)
+				wsDroppedFramesTotal.WithLabelValues(w.connID).Inc()
+			}
+			w.frames = append(w.frames, f)
+			w.bytes += len(f.data)
+		}
+
+	default: // PolicyBlock
+		for (len(w.frames) >= w.cfg.MaxMessages || w.bytes+len(f.data) > w.cfg.MaxBytes) && !w.closed {
+			w.cond.Wait()
+		}
+		if w.closed {
+			return
+		}
+		w.frames = append(w.frames, f)
+		w.bytes += len(f.data)
+	}
+
+	wsQueueDepth.WithLabelValues(w.connID).Set(float64(len(w.frames)))
+	w.cond.Signal()
+}
+
+// run drains control frames ahead of data frames, since pings and close
+// handshakes must not be starved by a backlogged consumer.
+func (w *connWriter) run() {
+	defer close(w.done)
+	for {
+		w.mu.Lock()
+		for len(w.frames) == 0 && len(w.control) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.control) == 0 && len(w.frames) == 0 && w.closed {
+			w.mu.Unlock()
+			return
+		}
+
+		var f queuedFrame
+		if len(w.control) > 0 {
+			f, w.control = w.control[0], w.control[1:]
+		} else {
+			f, w.frames = w.frames[0], w.frames[1:]
+			w.bytes -= len(f.data)
+			// Waking a producer blocked in PolicyBlock's Wait loop above.
+			w.cond.Signal()
+		}
+		wsQueueDepth.WithLabelValues(w.connID).Set(float64(len(w.frames)))
+		w.mu.Unlock()
+
+		if !w.write(f) {
+			w.markClosed()
+			return
+		}
+	}
+}
+
+func (w *connWriter) write(f queuedFrame) bool {
+	start := time.Now()
+	w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+	var err error
+	if f.isControl {
+		err = w.conn.WriteControl(f.msgType, f.data, time.Now().Add(writeWait))
+	} else {
+		err = w.conn.WriteMessage(f.msgType, f.data)
+	}
+
+	wsWriteDurationSeconds.WithLabelValues(w.connID).Observe(time.Since(start).Seconds())
+	if err != nil {
+		log.Printf("WebSocket write error: %v", err)
+		return false
+	}
+	return true
+}
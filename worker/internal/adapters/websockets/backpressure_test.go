@@ -0,0 +1,30 @@
+package websockets
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestCoalesceBoundsQueueWhenChannelsDiffer guards against the coalesce
+// policy growing the queue without bound when consecutive frames target
+// different channels (the common case: demuxTaskOutput interleaves a
+// ChannelStatus frame after every stdout/stderr chunk, so the merge branch
+// almost never triggers).
+func TestCoalesceBoundsQueueWhenChannelsDiffer(t *testing.T) {
+	w := &connWriter{
+		cfg: QueueConfig{MaxMessages: 3, MaxBytes: 1 << 20, Policy: PolicyCoalesce},
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	for i := 0; i < 10; i++ {
+		ch := ChannelStdout
+		if i%2 == 1 {
+			ch = ChannelStatus
+		}
+		w.enqueue(queuedFrame{data: encodeFrame(ch, []byte("x")), channel: int(ch)})
+	}
+
+	if len(w.frames) > w.cfg.MaxMessages {
+		t.Fatalf("queue depth = %d, want <= %d (MaxMessages)", len(w.frames), w.cfg.MaxMessages)
+	}
+}
@@ -0,0 +1,195 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// protocolVersion is the highest envelope version this server understands.
+// RegisterPayload.Versions lets a client advertise older versions it also
+// speaks; the server negotiates the highest offered version that does not
+// exceed protocolVersion.
+const protocolVersion = 1
+
+// MsgType discriminates the four kinds of envelope that can appear on the
+// wire once a connection has completed the register handshake.
+type MsgType string
+
+const (
+	MsgTypeRegister MsgType = "register"
+	MsgTypeRequest  MsgType = "request"
+	MsgTypeResponse MsgType = "response"
+	MsgTypeEvent    MsgType = "event"
+	MsgTypeError    MsgType = "error"
+)
+
+// RegisterPayload is the body of the client's handshake frame: the first
+// message sent on a connection that wants the versioned envelope protocol.
+// swagger:model
+type RegisterPayload struct {
+	ClientID     string   `json:"client_id"`
+	Versions     []int    `json:"versions"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// RegisterAck is returned in reply to a register handshake.
+// swagger:model
+type RegisterAck struct {
+	Version      int      `json:"version"`
+	SessionToken string   `json:"session_token"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// session tracks the negotiated state of one WebSocket connection once it
+// has completed the register handshake: which protocol version it speaks,
+// its self-reported capabilities, the token the server issued it, and the
+// event subscriptions it has open.
+type session struct {
+	clientID     string
+	token        string
+	version      int
+	capabilities []string
+
+	subsMu sync.Mutex
+	subs   map[string]context.CancelFunc
+}
+
+// addSubscription records cancel under subID so it can be torn down later by
+// removeSubscription, either from an explicit "unsubscribe" or when the
+// forwarding goroutine itself exits (e.g. connection closed).
+func (s *session) addSubscription(subID string, cancel context.CancelFunc) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if s.subs == nil {
+		s.subs = make(map[string]context.CancelFunc)
+	}
+	s.subs[subID] = cancel
+}
+
+// removeSubscription cancels and forgets the subscription registered under
+// subID, if any. It is safe to call more than once for the same subID (e.g.
+// once from an "unsubscribe" envelope and once from the forwarding goroutine
+// unwinding after cancel): only the first call finds it in the map and
+// actually cancels it.
+func (s *session) removeSubscription(subID string) {
+	s.subsMu.Lock()
+	cancel, ok := s.subs[subID]
+	if ok {
+		delete(s.subs, subID)
+	}
+	s.subsMu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// actionHandler implements one envelope action. Handlers write their own
+// responses/events through cw (long-running actions like create_task stream
+// many messages), rather than returning a single value.
+type actionHandler func(ctx context.Context, sess *session, cw *connWriter, env Envelope) error
+
+// Envelope is the versioned, correlatable message format exchanged once a
+// connection has registered. It is a superset of the legacy {action,
+// payload} shape, so existing JSON clients that never send "v"/"id"/"type"
+// keep working unmodified: those fields simply decode to their zero values.
+// swagger:model
+type Envelope struct {
+	// Version del protocolo de envelope. Ausente o 0 en clientes legacy.
+	Version int `json:"v,omitempty"`
+
+	// ID de correlación generado por quien origina el mensaje.
+	ID string `json:"id,omitempty"`
+
+	// Tipo de envelope: register, request, response, event o error.
+	Type MsgType `json:"type,omitempty"`
+
+	// Acción a realizar (create_task, stop_task, list_tasks, subscribe, ...)
+	Action string `json:"action" example:"create_task"`
+
+	// Payload de la acción.
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// ID del mensaje al que responde este envelope, si aplica.
+	InReplyTo string `json:"in_reply_to,omitempty"`
+}
+
+// newRegistry builds the action dispatch table used once a connection has
+// registered, replacing the old hand-rolled switch in HandleConnection so
+// new actions (subscribe, unsubscribe, ack, resume) can be added without
+// touching the read loop.
+func (h *WSHandler) newRegistry() map[string]actionHandler {
+	return map[string]actionHandler{
+		"create_task": func(ctx context.Context, sess *session, cw *connWriter, env Envelope) error {
+			h.handleCreateTask(ctx, cw, env.ID, env.Payload)
+			return nil
+		},
+		"stop_task": func(ctx context.Context, sess *session, cw *connWriter, env Envelope) error {
+			h.handleStopTask(ctx, cw, env.ID, env.Payload)
+			return nil
+		},
+		"list_tasks": func(ctx context.Context, sess *session, cw *connWriter, env Envelope) error {
+			h.handleListTasks(ctx, cw, env.ID)
+			return nil
+		},
+		"subscribe":   h.subscribeHandler,
+		"unsubscribe": h.unsubscribeHandler,
+	}
+}
+
+// handleRegister negotiates the protocol version and issues a session token
+// for a connection's handshake frame. It is the only action handled outside
+// the registry, since every other action requires a session to already
+// exist.
+func (h *WSHandler) handleRegister(cw *connWriter, env Envelope) (*session, error) {
+	var req RegisterPayload
+	if err := json.Unmarshal(env.Payload, &req); err != nil {
+		return nil, fmt.Errorf("error decoding register payload: %w", err)
+	}
+
+	negotiated := 0
+	for _, v := range req.Versions {
+		if v <= protocolVersion && v > negotiated {
+			negotiated = v
+		}
+	}
+	if negotiated == 0 {
+		return nil, fmt.Errorf("no compatible protocol version offered: %v", req.Versions)
+	}
+
+	sess := &session{
+		clientID:     req.ClientID,
+		token:        uuid.NewString(),
+		version:      negotiated,
+		capabilities: req.Capabilities,
+	}
+
+	ack := RegisterAck{
+		Version:      sess.version,
+		SessionToken: sess.token,
+		Capabilities: sess.capabilities,
+	}
+	return sess, h.writeEnvelope(cw, MsgTypeResponse, "register", env.ID, ack)
+}
+
+// writeEnvelope marshals data as the payload of a response/event envelope
+// and queues it for writing.
+func (h *WSHandler) writeEnvelope(cw *connWriter, typ MsgType, action, inReplyTo string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error serializando envelope: %w", err)
+	}
+
+	return cw.enqueueJSON(Envelope{
+		Version:   protocolVersion,
+		ID:        uuid.NewString(),
+		Type:      typ,
+		Action:    action,
+		Payload:   payload,
+		InReplyTo: inReplyTo,
+	})
+}
@@ -0,0 +1,101 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"dev.rubentxu.devops-platform/worker/internal/adapters/worker"
+
+	"github.com/google/uuid"
+)
+
+// SubscribeRequest selects which worker.Event values a connection wants to
+// receive. An empty filter matches nothing; set All to subscribe to every
+// event in the system, equivalent to a "*" filter.
+// swagger:model
+type SubscribeRequest struct {
+	TaskIDs     []string `json:"task_ids,omitempty"`
+	NameGlobs   []string `json:"name_globs,omitempty"`
+	States      []string `json:"states,omitempty"`
+	All         bool     `json:"all,omitempty"`
+}
+
+// toEventFilter adapts the wire-level SubscribeRequest to the broker's
+// worker.EventFilter.
+func (r SubscribeRequest) toEventFilter() worker.EventFilter {
+	return worker.EventFilter{
+		TaskIDs:   r.TaskIDs,
+		NameGlobs: r.NameGlobs,
+		States:    r.States,
+		All:       r.All,
+	}
+}
+
+// UnsubscribeRequest stops forwarding events for a previously returned
+// subscription ID.
+// swagger:model
+type UnsubscribeRequest struct {
+	SubscriptionID string `json:"subscription_id"`
+}
+
+// subscribeHandler implements the "subscribe" action: it registers a
+// listener on the worker's event bus and spawns a goroutine that forwards
+// matching events to the socket as "event" envelopes until the connection
+// closes or an "unsubscribe" envelope arrives for the same ID.
+func (h *WSHandler) subscribeHandler(ctx context.Context, sess *session, cw *connWriter, env Envelope) error {
+	if sess == nil {
+		return fmt.Errorf("subscribe requires a registered session")
+	}
+
+	var req SubscribeRequest
+	if err := json.Unmarshal(env.Payload, &req); err != nil {
+		return fmt.Errorf("error decoding subscribe request: %w", err)
+	}
+
+	subID := uuid.NewString()
+	events, cancel := h.worker.Events().Subscribe(req.toEventFilter())
+	sess.addSubscription(subID, cancel)
+
+	go func() {
+		defer sess.removeSubscription(subID)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := h.writeEnvelope(cw, MsgTypeEvent, string(evt.Kind), "", evt); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return h.writeEnvelope(cw, MsgTypeResponse, "subscribe", env.ID, map[string]string{
+		"subscription_id": subID,
+	})
+}
+
+// unsubscribeHandler drops the listener registered under
+// req.SubscriptionID: it cancels its context (unregistering it from the
+// broker) and lets the forwarding goroutine spawned by subscribeHandler
+// return on its own once that cancellation closes its events channel.
+func (h *WSHandler) unsubscribeHandler(ctx context.Context, sess *session, cw *connWriter, env Envelope) error {
+	if sess == nil {
+		return fmt.Errorf("unsubscribe requires a registered session")
+	}
+
+	var req UnsubscribeRequest
+	if err := json.Unmarshal(env.Payload, &req); err != nil {
+		return fmt.Errorf("error decoding unsubscribe request: %w", err)
+	}
+
+	sess.removeSubscription(req.SubscriptionID)
+
+	return h.writeEnvelope(cw, MsgTypeResponse, "unsubscribe", env.ID, map[string]string{
+		"subscription_id": req.SubscriptionID,
+	})
+}
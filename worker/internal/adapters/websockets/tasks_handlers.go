@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -28,6 +29,7 @@ const (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{protocolChannelV4},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // En producción, restringir a orígenes válidos
 	},
@@ -65,14 +67,13 @@ func (h *WSHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
+
+	connID := uuid.NewString()
+	cw := newConnWriter(conn, queueConfigFromQuery(r), connID)
 	defer func() {
 		// Cierre gradual con timeout extendido
-		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		conn.WriteControl(
-			websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Closing normally"),
-			time.Now().Add(3*time.Second),
-		)
+		cw.enqueueControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Closing normally"))
+		cw.Close()
 		conn.Close()
 	}()
 
@@ -86,91 +87,198 @@ func (h *WSHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 
-	go h.sendPing(ctx, conn)
+	go h.sendPing(ctx, cw)
+
+	if conn.Subprotocol() == protocolChannelV4 {
+		h.handleMultiplexedConnection(ctx, conn, cw)
+		return
+	}
+
+	registry := h.newRegistry()
+	var sess *session
 
 	for {
-		var msg WSMessage
-		if err := conn.ReadJSON(&msg); err != nil {
+		var env Envelope
+		if err := conn.ReadJSON(&env); err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
-		switch msg.Action {
-		case "create_task":
-			h.handleCreateTask(ctx, conn, msg.Payload)
-		case "stop_task":
-			h.handleStopTask(ctx, conn, msg.Payload)
-		case "list_tasks":
-			h.handleListTasks(ctx, conn)
+		if env.Type == MsgTypeRegister {
+			negotiated, err := h.handleRegister(cw, env)
+			if err != nil {
+				h.sendError(cw, "register_error", err.Error())
+				continue
+			}
+			sess = negotiated
+			continue
+		}
+
+		handler, ok := registry[env.Action]
+		if !ok {
+			h.sendError(cw, "unknown_action", "Unsupported action type")
+			continue
+		}
+		if err := handler(ctx, sess, cw, env); err != nil {
+			h.sendError(cw, "action_error", err.Error())
+		}
+	}
+}
+
+// queueConfigFromQuery reads the `backpressure`, `queue_messages`, and
+// `queue_bytes` query params off the upgrade request, falling back to
+// DefaultQueueConfig for anything unset or invalid.
+func queueConfigFromQuery(r *http.Request) QueueConfig {
+	cfg := DefaultQueueConfig()
+	cfg.Policy = ParsePolicy(r.URL.Query().Get("backpressure"))
+
+	if n, err := strconv.Atoi(r.URL.Query().Get("queue_messages")); err == nil && n > 0 {
+		cfg.MaxMessages = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("queue_bytes")); err == nil && n > 0 {
+		cfg.MaxBytes = n
+	}
+
+	return cfg
+}
+
+// handleMultiplexedConnection runs the v4.channel.remoteprocess protocol: the
+// first frame is a bare JSON TaskRequest (the create_task payload, with no
+// envelope around it; there is only ever one task per multiplexed connection
+// today), but all subsequent traffic is raw binary frames carrying a channel
+// prefix. stdout/stderr/status are demuxed from the task's output channel by
+// demuxTaskOutput; here we read the inbound control-in (stdin) and resize
+// frames and log them. Neither is forwarded to the running task.
+func (h *WSHandler) handleMultiplexedConnection(ctx context.Context, conn *websocket.Conn, cw *connWriter) {
+	var req TaskRequest
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		log.Printf("WebSocket error reading initial create_task frame: %v", err)
+		return
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		h.sendError(cw, "invalid_request", "Error decoding task request")
+		return
+	}
+	if req.Name == "" || req.Image == "" {
+		h.sendError(cw, "validation_error", "Name and Image are required fields")
+		return
+	}
+
+	task, taskCtx := h.createTaskFromRequest(ctx, req)
+	outputChan, err := h.worker.AddTask(taskCtx, task)
+	if err != nil {
+		h.sendError(cw, "create_error", fmt.Sprintf("Error creating task: %v", err))
+		return
+	}
+
+	go h.demuxTaskOutput(taskCtx, cw, task.ID.String(), outputChan)
+
+	for {
+		msgType, raw, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+				log.Printf("WebSocket error: %v", err)
+			}
+			return
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		ch, payload, err := decodeFrame(raw)
+		if err != nil {
+			log.Printf("Error decoding multiplexed frame: %v", err)
+			continue
+		}
+
+		switch ch {
+		case ChannelControlIn:
+			// Stdin bytes are logged and dropped; they do not reach the
+			// task's process.
+			log.Printf("Received %d bytes on control-in for task %s", len(payload), task.ID)
+		case ChannelResize:
+			resize, err := decodeResize(payload)
+			if err != nil {
+				log.Printf("Error decoding resize frame: %v", err)
+				continue
+			}
+			log.Printf("Resize request for task %s: %dx%d", task.ID, resize.Cols, resize.Rows)
 		default:
-			h.sendError(conn, "unknown_action", "Unsupported action type")
+			log.Printf("Unexpected inbound channel %s on task %s", ch, task.ID)
+		}
+	}
+}
+
+// demuxTaskOutput splits a task's ProcessOutput channel into stdout/stderr
+// binary frames, picking the channel from output.IsError, and after every
+// chunk emits that output's status as compact JSON on ChannelStatus.
+func (h *WSHandler) demuxTaskOutput(ctx context.Context, cw *connWriter, taskID string, outputChan <-chan *domain.ProcessOutput) {
+	for output := range outputChan {
+		ch := ChannelStdout
+		if output.IsError {
+			ch = ChannelStderr
+		}
+		cw.enqueueBinary(ch, []byte(output.Output))
+
+		statusPayload, err := json.Marshal(map[string]interface{}{
+			"task_id": taskID,
+			"status":  output.Status.String(),
+		})
+		if err != nil {
+			log.Printf("Error serializando status para tarea %s: %v", taskID, err)
+			continue
 		}
+		cw.enqueueBinary(ChannelStatus, statusPayload)
 	}
 }
 
-func (h *WSHandler) handleCreateTask(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) {
+func (h *WSHandler) handleCreateTask(ctx context.Context, cw *connWriter, replyTo string, payload json.RawMessage) {
 	var req TaskRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		h.sendError(conn, "invalid_request", "Error decoding task request")
+		h.sendErrorReply(cw, replyTo, "invalid_request", "Error decoding task request")
 		return
 	}
 
 	if req.Name == "" || req.Image == "" {
-		h.sendError(conn, "validation_error", "Name and Image are required fields")
+		h.sendErrorReply(cw, replyTo, "validation_error", "Name and Image are required fields")
 		return
 	}
 
 	task, taskCtx := h.createTaskFromRequest(ctx, req)
 	outputChan, err := h.worker.AddTask(taskCtx, task)
 	if err != nil {
-		h.sendError(conn, "create_error", fmt.Sprintf("Error creating task: %v", err))
+		h.sendErrorReply(cw, replyTo, "create_error", fmt.Sprintf("Error creating task: %v", err))
 		return
 	}
 
-	// Leer del canal y enviar por WebSocket
+	// Leer del canal y enviar por WebSocket, correlacionado con la petición
+	// que los originó para que un cliente con varias create_task en vuelo
+	// sobre el mismo socket sepa a cuál pertenece cada output.
 	for output := range outputChan {
-		resp := TaskResponse{
+		if err := h.writeEnvelope(cw, MsgTypeEvent, "task_output", replyTo, TaskResponse{
 			TaskID:  task.ID.String(),
 			Output:  output.Output,
 			IsError: output.IsError,
 			Status:  output.Status.String(),
-		}
-
-		payload, err := json.Marshal(resp)
-		if err != nil {
-			log.Printf("Error serializando respuesta: %v", err)
-			return
-		}
-
-		msg := WSMessage{
-			Action:  "task_output",
-			Payload: json.RawMessage(payload),
-		}
-
-		if err := conn.WriteJSON(msg); err != nil {
-			log.Printf("Error sending WebSocket message: %v", err)
-			return
+		}); err != nil {
+			log.Printf("Error sending task_output: %v", err)
 		}
 	}
 
 	// CUANDO el proceso finaliza, enviar un mensaje final "done"
-	doneMsg := map[string]interface{}{
+	if err := h.writeEnvelope(cw, MsgTypeResponse, "task_done", replyTo, map[string]interface{}{
 		"done":      true,
 		"exit_code": 0,
 		"message":   "Process completed successfully",
 		"status":    domain.FINISHED.String(),
-	}
-	if err := conn.WriteJSON(doneMsg); err != nil {
+	}); err != nil {
 		log.Printf("Error sending done message: %v", err)
 	}
 
 	// (Opcional) Enviar un CloseMessage indicando cierre normal
-	_ = conn.WriteMessage(
-		websocket.CloseMessage,
-		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Process finished"),
-	)
+	cw.enqueueControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Process finished"))
 }
 
 func (h *WSHandler) createTaskFromRequest(parent context.Context, req TaskRequest) (domain.Task, context.Context) {
@@ -199,20 +307,17 @@ func (h *WSHandler) createTaskFromRequest(parent context.Context, req TaskReques
 	}, taskCtx
 }
 
-func (h *WSHandler) streamTaskOutput(ctx context.Context, conn *websocket.Conn, taskID string, outputChan <-chan *domain.ProcessOutput) {
-	defer h.sendTaskCompletion(ctx, conn, taskID)
+func (h *WSHandler) streamTaskOutput(ctx context.Context, cw *connWriter, taskID string, outputChan <-chan *domain.ProcessOutput) {
+	defer h.sendTaskCompletion(ctx, cw, taskID)
 
 	for output := range outputChan {
 		// Enviar cada output inmediatamente por el WebSocket
-		if !h.sendJSON(conn, "task_output", TaskResponse{
+		h.sendJSON(cw, "task_output", TaskResponse{
 			TaskID:  taskID,
 			Output:  output.Output,
 			IsError: output.IsError,
 			Status:  output.Status.String(),
-		}) {
-			log.Printf("Error enviando output, cerrando conexión para tarea %s", taskID)
-			return
-		}
+		})
 
 		// Verificar periodicamente si el contexto fue cancelado
 		select {
@@ -222,15 +327,15 @@ func (h *WSHandler) streamTaskOutput(ctx context.Context, conn *websocket.Conn,
 			return
 		default:
 			// Mantener conexión activa con ping
-			conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			cw.enqueueControl(websocket.PingMessage, nil)
 		}
 	}
 }
 
-func (h *WSHandler) sendTaskCompletion(ctx context.Context, conn *websocket.Conn, taskID string) {
+func (h *WSHandler) sendTaskCompletion(ctx context.Context, cw *connWriter, taskID string) {
 	task, err := h.worker.GetTask(taskID)
 	if err != nil {
-		h.sendError(conn, "task_error", fmt.Sprintf("Error getting task status: %v", err))
+		h.sendError(cw, "task_error", fmt.Sprintf("Error getting task status: %v", err))
 		return
 	}
 
@@ -247,84 +352,95 @@ func (h *WSHandler) sendTaskCompletion(ctx context.Context, conn *websocket.Conn
 		resp.Error = "Task failed to complete"
 	}
 
-	h.sendJSON(conn, "task_completed", resp)
+	h.sendJSON(cw, "task_completed", resp)
 }
 
-func (h *WSHandler) handleStopTask(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) {
+func (h *WSHandler) handleStopTask(ctx context.Context, cw *connWriter, replyTo string, payload json.RawMessage) {
 	var req struct {
 		TaskID string `json:"task_id"`
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
-		h.sendError(conn, "invalid_request", "Invalid task ID format")
+		h.sendErrorReply(cw, replyTo, "invalid_request", "Invalid task ID format")
 		return
 	}
 
 	if err := h.worker.StopTask(req.TaskID); err != nil {
-		h.sendError(conn, "stop_error", err.Error())
+		h.sendErrorReply(cw, replyTo, "stop_error", err.Error())
 		return
 	}
 
-	h.sendJSON(conn, "task_stopped", TaskResponse{
+	if err := h.writeEnvelope(cw, MsgTypeResponse, "task_stopped", replyTo, TaskResponse{
 		TaskID: req.TaskID,
 		Status: "stopped",
-	})
+	}); err != nil {
+		log.Printf("Error sending task_stopped: %v", err)
+	}
 }
 
-func (h *WSHandler) handleListTasks(ctx context.Context, conn *websocket.Conn) {
+func (h *WSHandler) handleListTasks(ctx context.Context, cw *connWriter, replyTo string) {
 	tasks, err := h.worker.GetTasks()
 	if err != nil {
-		h.sendError(conn, "list_error", "Error retrieving tasks")
+		h.sendErrorReply(cw, replyTo, "list_error", "Error retrieving tasks")
 		return
 	}
 
-	h.sendJSON(conn, "task_list", tasks)
+	if err := h.writeEnvelope(cw, MsgTypeResponse, "task_list", replyTo, tasks); err != nil {
+		log.Printf("Error sending task_list: %v", err)
+	}
 }
 
-func (h *WSHandler) sendPing(ctx context.Context, conn *websocket.Conn) {
+func (h *WSHandler) sendPing(ctx context.Context, cw *connWriter) {
 	ticker := time.NewTicker(pingPeriod)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
+			cw.enqueueControl(websocket.PingMessage, nil)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (h *WSHandler) sendJSON(conn *websocket.Conn, action string, data interface{}) bool {
+func (h *WSHandler) sendJSON(cw *connWriter, action string, data interface{}) bool {
 	raw, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("Error serializando payload: %v", err)
 		return false
 	}
 
-	msg := WSMessage{
+	if err := cw.enqueueJSON(WSMessage{
 		Action:  action,
 		Payload: json.RawMessage(raw),
-	}
-
-	conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := conn.WriteJSON(msg); err != nil {
+	}); err != nil {
 		log.Printf("WebSocket write error: %v", err)
 		return false
 	}
 	return true
 }
 
-func (h *WSHandler) sendError(conn *websocket.Conn, code string, message string) {
-	h.sendJSON(conn, "task_error", TaskResponse{
+func (h *WSHandler) sendError(cw *connWriter, code string, message string) {
+	h.sendJSON(cw, "task_error", TaskResponse{
 		IsError:  true,
 		ExitCode: code,
 		Error:    message,
 	})
 }
 
+// sendErrorReply is sendError's envelope-protocol counterpart: it tags the
+// error with in_reply_to so a client juggling several in-flight requests on
+// one socket can tell which one failed.
+func (h *WSHandler) sendErrorReply(cw *connWriter, replyTo string, code string, message string) {
+	if err := h.writeEnvelope(cw, MsgTypeError, code, replyTo, TaskResponse{
+		IsError:  true,
+		ExitCode: code,
+		Error:    message,
+	}); err != nil {
+		log.Printf("Error sending error reply: %v", err)
+	}
+}
+
 // @Summary Endpoint de health check
 // @Description Retorna el estado de salud del servicio
 // @Tags health
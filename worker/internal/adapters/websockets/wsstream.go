@@ -0,0 +1,79 @@
+package websockets
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// protocolChannelV4 is the WebSocket subprotocol that opts a connection into
+// the multiplexed binary framing implemented in this file. Clients that do
+// not request this subprotocol during the handshake keep talking the legacy
+// single-envelope JSON protocol untouched.
+const protocolChannelV4 = "v4.channel.remoteprocess"
+
+// Channel identifies the logical stream a multiplexed frame belongs to,
+// mirroring the `kubectl exec` channel layout.
+type Channel byte
+
+const (
+	ChannelStdout    Channel = 0
+	ChannelStderr    Channel = 1
+	ChannelStatus    Channel = 2
+	ChannelControlIn Channel = 3
+	ChannelResize    Channel = 4
+)
+
+func (c Channel) String() string {
+	switch c {
+	case ChannelStdout:
+		return "stdout"
+	case ChannelStderr:
+		return "stderr"
+	case ChannelStatus:
+		return "status"
+	case ChannelControlIn:
+		return "control-in"
+	case ChannelResize:
+		return "resize"
+	default:
+		return "unknown"
+	}
+}
+
+// ResizeEvent is the payload carried on ChannelResize: rows/cols encoded as
+// little-endian uint16, making room for PTY support without changing the
+// framing itself.
+type ResizeEvent struct {
+	Rows uint16
+	Cols uint16
+}
+
+// decodeResize parses a ChannelResize payload produced by the client.
+func decodeResize(payload []byte) (ResizeEvent, error) {
+	if len(payload) != 4 {
+		return ResizeEvent{}, fmt.Errorf("invalid resize payload length: %d", len(payload))
+	}
+	return ResizeEvent{
+		Rows: binary.LittleEndian.Uint16(payload[0:2]),
+		Cols: binary.LittleEndian.Uint16(payload[2:4]),
+	}, nil
+}
+
+// encodeFrame prefixes payload with its single-byte channel, ready to be
+// written as a binary WebSocket message.
+func encodeFrame(ch Channel, payload []byte) []byte {
+	frame := make([]byte, 1+len(payload))
+	frame[0] = byte(ch)
+	copy(frame[1:], payload)
+	return frame
+}
+
+// decodeFrame splits a binary WebSocket message into its channel prefix and
+// payload. It returns an error for empty messages, which are never valid
+// under this framing.
+func decodeFrame(msg []byte) (Channel, []byte, error) {
+	if len(msg) == 0 {
+		return 0, nil, fmt.Errorf("empty multiplexed frame")
+	}
+	return Channel(msg[0]), msg[1:], nil
+}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"path"
+	"sync"
+)
+
+// EventKind identifies the kind of task transition or output chunk a
+// published Event represents.
+type EventKind string
+
+const (
+	EventTaskCreated   EventKind = "task_created"
+	EventTaskRunning   EventKind = "task_running"
+	EventTaskOutput    EventKind = "task_output"
+	EventTaskCompleted EventKind = "task_completed"
+	EventTaskFailed    EventKind = "task_failed"
+	EventTaskStopped   EventKind = "task_stopped"
+)
+
+// Event is one item published on the Broker: a single task state transition,
+// tagged with enough metadata for subscribers to filter on without
+// re-fetching the task from the Worker.
+type Event struct {
+	Kind       EventKind `json:"kind"`
+	TaskID     string    `json:"task_id"`
+	Name       string    `json:"name,omitempty"`
+	State      string    `json:"state,omitempty"`
+	OccurredAt string    `json:"occurred_at,omitempty"`
+}
+
+// EventFilter selects which Events a subscriber receives. All subscribes to
+// every Event regardless of the other fields; otherwise an Event matches
+// only if every non-empty field matches (TaskIDs/States are exact-match
+// sets, NameGlobs is matched with path.Match-style wildcards). A zero-value
+// EventFilter matches nothing.
+type EventFilter struct {
+	TaskIDs   []string
+	NameGlobs []string
+	States    []string
+	All       bool
+}
+
+func (f EventFilter) matches(evt Event) bool {
+	if f.All {
+		return true
+	}
+	if len(f.TaskIDs) == 0 && len(f.NameGlobs) == 0 && len(f.States) == 0 {
+		return false
+	}
+	if len(f.TaskIDs) > 0 && !containsString(f.TaskIDs, evt.TaskID) {
+		return false
+	}
+	if len(f.States) > 0 && !containsString(f.States, evt.State) {
+		return false
+	}
+	if len(f.NameGlobs) > 0 && !matchesAnyGlob(f.NameGlobs, evt.Name) {
+		return false
+	}
+	return true
+}
+
+func containsString(ss []string, v string) bool {
+	for _, s := range ss {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyGlob(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// subscription is one registered listener: the filter it was created with
+// and the channel events are delivered on.
+type subscription struct {
+	filter EventFilter
+	ch     chan Event
+}
+
+// Broker is an in-process pub/sub bus for task lifecycle Events. Worker
+// publishes to it as tasks are created, transition state, and finish;
+// WSHandler's "subscribe" action and the GraphQL taskEvents subscription
+// both call Subscribe to forward matching Events to their own transport.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[int]*subscription
+	nextID int
+}
+
+// NewBroker builds an empty Broker ready to accept subscribers.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[int]*subscription)}
+}
+
+// Subscribe registers a listener for Events matching filter and returns a
+// channel of matching Events plus a cancel func that unregisters the
+// listener and closes the channel. Callers must call cancel exactly once,
+// whether on an explicit unsubscribe or because they stopped reading (e.g.
+// the connection disconnected).
+func (b *Broker) Subscribe(filter EventFilter) (<-chan Event, context.CancelFunc) {
+	sub := &subscription{filter: filter, ch: make(chan Event, 16)}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Publish fans evt out to every subscriber whose filter matches it. A
+// subscriber that isn't keeping up with its buffered channel has the event
+// dropped for it rather than blocking the publisher.
+func (b *Broker) Publish(evt Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, sub := range b.subs {
+		if !sub.filter.matches(evt) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+		}
+	}
+}
+
+// Events returns the Worker's event broker. Worker's other fields (task
+// store, backend clients, ...) are declared alongside AddTask/StopTask/
+// GetTask/GetTasks; this file only adds the broker itself and this
+// accessor, on the assumption that Worker already carries an `events
+// *Broker` field initialized by NewBroker() in its constructor.
+func (w *Worker) Events() *Broker {
+	return w.events
+}